@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestTokenizeResponseFileLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"# a comment", nil},
+		{"  # indented comment", nil},
+		{"--bundle", []string{"--bundle"}},
+		{"--outfile=out.js --bundle", []string{"--outfile=out.js", "--bundle"}},
+		{"--outfile='out file.js'", []string{"--outfile=out file.js"}},
+		{`--outfile="out file.js"`, []string{"--outfile=out file.js"}},
+		{"--define:FOO='bar baz'", []string{"--define:FOO=bar baz"}},
+	}
+
+	for _, test := range tests {
+		tokens, err := tokenizeResponseFileLine(test.line, "test.txt", 1)
+		if err != nil {
+			t.Fatalf("tokenizeResponseFileLine(%q) returned unexpected error: %s", test.line, err.Text)
+		}
+		if !reflect.DeepEqual(tokens, test.expected) {
+			t.Errorf("tokenizeResponseFileLine(%q) = %#v, want %#v", test.line, tokens, test.expected)
+		}
+	}
+}
+
+func TestTokenizeResponseFileLineUnterminatedQuote(t *testing.T) {
+	if _, err := tokenizeResponseFileLine(`--define:FOO='bar`, "test.txt", 1); err == nil {
+		t.Fatal("expected an error for an unterminated quote, got none")
+	}
+}
+
+func TestSplitConfigPathAndName(t *testing.T) {
+	tests := []struct {
+		value        string
+		expectedPath string
+		expectedName string
+	}{
+		{"esbuild.json", "esbuild.json", ""},
+		{"esbuild.json#dev", "esbuild.json", "dev"},
+		{"esbuild.json#", "esbuild.json", ""},
+		{"configs/esbuild.json#prod", "configs/esbuild.json", "prod"},
+	}
+
+	for _, test := range tests {
+		path, name := splitConfigPathAndName(test.value)
+		if path != test.expectedPath || name != test.expectedName {
+			t.Errorf("splitConfigPathAndName(%q) = (%q, %q), want (%q, %q)",
+				test.value, path, name, test.expectedPath, test.expectedName)
+		}
+	}
+}
+
+func TestResolveLoaderByMime(t *testing.T) {
+	mimeByExt := map[string]string{
+		".svg": "image/svg+xml",
+		".txt": "text/plain",
+	}
+	loaderByMime := map[string]api.Loader{
+		"image/svg+xml": api.LoaderText,
+	}
+
+	if loader, ok := resolveLoaderByMime(".svg", mimeByExt, loaderByMime); !ok || loader != api.LoaderText {
+		t.Errorf("resolveLoaderByMime(\".svg\") = (%v, %v), want (%v, true)", loader, ok, api.LoaderText)
+	}
+
+	// The media type is declared but has no loader assigned to it
+	if _, ok := resolveLoaderByMime(".txt", mimeByExt, loaderByMime); ok {
+		t.Error("resolveLoaderByMime(\".txt\") should fail since \"text/plain\" has no loader")
+	}
+
+	// The extension was never declared in "--mime:" at all
+	if _, ok := resolveLoaderByMime(".png", mimeByExt, loaderByMime); ok {
+		t.Error("resolveLoaderByMime(\".png\") should fail since it was never declared")
+	}
+}
+
+func TestSharedBatchOptionsToArgs(t *testing.T) {
+	shared := map[string]interface{}{
+		"bundle":    true,
+		"minify":    false,
+		"outbase":   "src",
+		"supported": map[string]interface{}{"arrow": false},
+		"external":  []interface{}{"react"},
+	}
+	expected := []string{
+		"--bundle=true", "--external:react", "--minify=false", "--outbase=src", "--supported:arrow=false",
+	}
+
+	// The args are sorted, so the result is deterministic even though map
+	// iteration order isn't
+	args, err := sharedBatchOptionsToArgs(shared)
+	if err != nil {
+		t.Fatalf("sharedBatchOptionsToArgs(...) returned unexpected error: %s", err.Text)
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("sharedBatchOptionsToArgs(...) = %#v, want %#v", args, expected)
+	}
+}
+
+func TestSharedBatchOptionsToArgsRejectsUnsupportedValues(t *testing.T) {
+	tests := []map[string]interface{}{
+		{"engines": []interface{}{map[string]interface{}{"name": "node"}}},
+		{"supported": map[string]interface{}{"arrow": 1.0}},
+		{"plugins": map[string]interface{}{"nested": map[string]interface{}{}}},
+	}
+
+	for _, shared := range tests {
+		if _, err := sharedBatchOptionsToArgs(shared); err == nil {
+			t.Errorf("sharedBatchOptionsToArgs(%#v) should have failed instead of silently dropping the value", shared)
+		}
+	}
+}
+
+func TestBatchGroupArgs(t *testing.T) {
+	group := batchGroupJSON{
+		EntryPoints: []string{"a.ts", "b.ts"},
+		Loader:      map[string]string{".png": "dataurl"},
+		Define:      map[string]string{"DEBUG": "false"},
+		External:    []string{"react"},
+		Outdir:      "out",
+		Format:      "esm",
+	}
+	expected := []string{
+		"--define:DEBUG=false",
+		"--external:react",
+		"--format=esm",
+		"--loader:.png=dataurl",
+		"--outdir=out",
+		"a.ts",
+		"b.ts",
+	}
+
+	// The flags are sorted for determinism, but the entry points are appended
+	// afterward in their original order since they're positional, not flags
+	if args := batchGroupArgs(group); !reflect.DeepEqual(args, expected) {
+		t.Errorf("batchGroupArgs(...) = %#v, want %#v", args, expected)
+	}
+}
+
+// Satisfies "api.BuildContext" without needing a real build, just to track
+// whether "Dispose" was called on it
+type fakeBuildContext struct {
+	disposed bool
+}
+
+func (f *fakeBuildContext) Rebuild() api.BuildResult             { return api.BuildResult{} }
+func (f *fakeBuildContext) Watch(options api.WatchOptions) error { return nil }
+func (f *fakeBuildContext) Serve(options api.ServeOptions) (api.ServeResult, error) {
+	return api.ServeResult{}, nil
+}
+func (f *fakeBuildContext) Cancel()  {}
+func (f *fakeBuildContext) Dispose() { f.disposed = true }
+
+func TestServeAPIServerTouchLRUReorders(t *testing.T) {
+	s := &serveAPIServer{}
+	s.touchLRULocked("a")
+	s.touchLRULocked("b")
+	s.touchLRULocked("c")
+	s.touchLRULocked("a") // Re-touching "a" should move it to the most-recently-used end
+
+	expected := []string{"b", "c", "a"}
+	if !reflect.DeepEqual(s.lru, expected) {
+		t.Errorf("lru = %#v, want %#v", s.lru, expected)
+	}
+}
+
+func TestServeAPIServerEvictLRU(t *testing.T) {
+	s := &serveAPIServer{contexts: make(map[string]*cachedBuildContext)}
+	fakes := make(map[string]*fakeBuildContext)
+
+	for i := 0; i < maxCachedBuildContexts+3; i++ {
+		key := fmt.Sprintf("key%d", i)
+		fake := &fakeBuildContext{}
+		fakes[key] = fake
+		s.contexts[key] = &cachedBuildContext{ctx: fake}
+		s.touchLRULocked(key)
+		s.evictLRULocked()
+	}
+
+	if len(s.contexts) != maxCachedBuildContexts {
+		t.Fatalf("expected %d cached contexts, got %d", maxCachedBuildContexts, len(s.contexts))
+	}
+
+	// The 3 oldest contexts should have been evicted and disposed
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if _, ok := s.contexts[key]; ok {
+			t.Errorf("expected %q to have been evicted", key)
+		}
+		if !fakes[key].disposed {
+			t.Errorf("expected %q's context to have been disposed", key)
+		}
+	}
+
+	// The rest should still be cached and untouched
+	for i := 3; i < maxCachedBuildContexts+3; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if _, ok := s.contexts[key]; !ok {
+			t.Errorf("expected %q to still be cached", key)
+		}
+		if fakes[key].disposed {
+			t.Errorf("expected %q's context not to have been disposed", key)
+		}
+	}
+}
+
+func TestApplyLogOverride(t *testing.T) {
+	buildOpts := newBuildOptions()
+	if err := applyLogOverride(&buildOpts, nil, "css-syntax-error", "error", "--log-override:css-syntax-error=error"); err != nil {
+		t.Fatalf("applyLogOverride(...) returned unexpected error: %s", err.Text)
+	}
+	if buildOpts.LogOverride["css-syntax-error"] != api.LogLevelError {
+		t.Errorf("LogOverride[\"css-syntax-error\"] = %v, want %v", buildOpts.LogOverride["css-syntax-error"], api.LogLevelError)
+	}
+
+	// Same helper is shared by "--log-level=name=level"; it must write into
+	// "transformOpts.LogOverride" when "buildOpts" is nil, the same way the
+	// rest of "parseOptionsImpl" distinguishes build vs. transform calls
+	transformOpts := newTransformOptions()
+	if err := applyLogOverride(nil, &transformOpts, "unsupported-css-property", "warning", "--log-level=unsupported-css-property=warning"); err != nil {
+		t.Fatalf("applyLogOverride(...) returned unexpected error: %s", err.Text)
+	}
+	if transformOpts.LogOverride["unsupported-css-property"] != api.LogLevelWarning {
+		t.Errorf("LogOverride[\"unsupported-css-property\"] = %v, want %v", transformOpts.LogOverride["unsupported-css-property"], api.LogLevelWarning)
+	}
+}
+
+func TestApplyLogOverrideInvalidLevel(t *testing.T) {
+	buildOpts := newBuildOptions()
+	if err := applyLogOverride(&buildOpts, nil, "css-syntax-error", "not-a-level", "--log-override:css-syntax-error=not-a-level"); err == nil {
+		t.Fatal("expected an error for an invalid log level, got none")
+	}
+}